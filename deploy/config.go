@@ -0,0 +1,96 @@
+package deploy
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// FilenameConfigJSON and FilenameConfigYAML are the two config file names
+// LoadConfig looks for under FilepathDosxvpnConfigDir, tried in that order.
+const (
+	FilenameConfigJSON = "config.json"
+	FilenameConfigYAML = "config.yaml"
+)
+
+// DeploymentConfig is one named entry in Config.Deployments. It mirrors the
+// subset of Deployment fields a user would otherwise pass on the command
+// line, so `dosxvpn up <name>` can be idempotent and scriptable.
+type DeploymentConfig struct {
+	Provider      string `json:"provider" yaml:"provider"`
+	Token         string `json:"token" yaml:"token"`
+	Region        string `json:"region" yaml:"region"`
+	Size          string `json:"size" yaml:"size"`
+	Image         string `json:"image" yaml:"image"`
+	Protocol      string `json:"protocol" yaml:"protocol"`
+	DNSFilter     string `json:"dns_filter" yaml:"dns_filter"`
+	AutoConfigure bool   `json:"auto_configure" yaml:"auto_configure"`
+}
+
+// Config is the declarative, on-disk description of the deployments a user
+// wants dosxvpn to manage, keyed by deployment name.
+type Config struct {
+	Deployments map[string]DeploymentConfig `json:"deployments" yaml:"deployments"`
+}
+
+// LoadConfig reads FilepathDosxvpnConfigDir/config.json, falling back to
+// config.yaml if the JSON file isn't present.
+func LoadConfig() (*Config, error) {
+	if data, err := ioutil.ReadFile(filepath.Join(FilepathDosxvpnConfigDir, FilenameConfigJSON)); err == nil {
+		cfg := &Config{}
+		if err := json.Unmarshal(data, cfg); err != nil {
+			return nil, err
+		}
+		return cfg, nil
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(FilepathDosxvpnConfigDir, FilenameConfigYAML))
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{}
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// NewFromConfig returns the Deployment for name described by cfg. If name
+// already has persisted state on disk, it resumes that deployment instead
+// of creating a new one, so calling this repeatedly for the same name is
+// idempotent.
+func NewFromConfig(cfg *Config, name string) (*Deployment, error) {
+	if _, err := os.Stat(statePath(name)); err == nil {
+		return Resume(name)
+	}
+
+	dc, ok := cfg.Deployments[name]
+	if !ok {
+		return nil, &ConfigError{Name: name}
+	}
+
+	d, err := New(dc.Provider, dc.Protocol, dc.Token, dc.Region, dc.DNSFilter, dc.AutoConfigure)
+	if err != nil {
+		return nil, err
+	}
+	d.Name = name
+	if dc.Size != "" {
+		d.Size = dc.Size
+	}
+	if dc.Image != "" {
+		d.Image = dc.Image
+	}
+	return d, nil
+}
+
+// ConfigError reports that Name has no entry in the loaded Config.
+type ConfigError struct {
+	Name string
+}
+
+func (e *ConfigError) Error() string {
+	return "no deployment named " + e.Name + " in config"
+}
@@ -0,0 +1,90 @@
+package deploy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func withTempConfigDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "dosxvpn-config-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	origConfigDir := FilepathDosxvpnConfigDir
+	FilepathDosxvpnConfigDir = dir
+	t.Cleanup(func() { FilepathDosxvpnConfigDir = origConfigDir })
+	return dir
+}
+
+func TestLoadConfigJSON(t *testing.T) {
+	dir := withTempConfigDir(t)
+	json := `{"deployments": {"home": {"provider": "aws", "token": "t", "region": "us-east-1", "size": "t3.micro", "image": "ami-123"}}}`
+	if err := ioutil.WriteFile(filepath.Join(dir, FilenameConfigJSON), []byte(json), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	dc, ok := cfg.Deployments["home"]
+	if !ok {
+		t.Fatal("Deployments has no \"home\" entry")
+	}
+	if dc.Provider != "aws" || dc.Region != "us-east-1" || dc.Size != "t3.micro" || dc.Image != "ami-123" {
+		t.Errorf("unexpected DeploymentConfig: %+v", dc)
+	}
+}
+
+func TestLoadConfigYAMLFallback(t *testing.T) {
+	dir := withTempConfigDir(t)
+	yaml := "deployments:\n  home:\n    provider: linode\n    region: us-east\n"
+	if err := ioutil.WriteFile(filepath.Join(dir, FilenameConfigYAML), []byte(yaml), 0644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	dc, ok := cfg.Deployments["home"]
+	if !ok {
+		t.Fatal("Deployments has no \"home\" entry")
+	}
+	if dc.Provider != "linode" || dc.Region != "us-east" {
+		t.Errorf("unexpected DeploymentConfig: %+v", dc)
+	}
+}
+
+func TestNewFromConfigUnknownName(t *testing.T) {
+	withTempConfigDir(t)
+	cfg := &Config{Deployments: map[string]DeploymentConfig{}}
+
+	_, err := NewFromConfig(cfg, "missing")
+	if _, ok := err.(*ConfigError); !ok {
+		t.Fatalf("NewFromConfig err = %v (%T), want *ConfigError", err, err)
+	}
+}
+
+func TestNewFromConfigAppliesSizeAndImage(t *testing.T) {
+	withTempConfigDir(t)
+	cfg := &Config{Deployments: map[string]DeploymentConfig{
+		"home": {Provider: ProviderAWS, Region: "us-east-1", Size: "t3.micro", Image: "ami-123"},
+	}}
+
+	d, err := NewFromConfig(cfg, "home")
+	if err != nil {
+		t.Fatalf("NewFromConfig: %v", err)
+	}
+	if d.Name != "home" {
+		t.Errorf("Name = %q, want %q", d.Name, "home")
+	}
+	if d.Size != "t3.micro" || d.Image != "ami-123" {
+		t.Errorf("Size/Image = %q/%q, want the config's values", d.Size, d.Image)
+	}
+}
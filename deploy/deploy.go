@@ -2,6 +2,7 @@ package deploy
 
 import (
 	"bytes"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -16,19 +17,79 @@ import (
 
 	"github.com/dan-v/dosxvpn/genconfig"
 
-	"github.com/dan-v/dosxvpn/doclient"
+	"github.com/dan-v/dosxvpn/provider"
+	"github.com/dan-v/dosxvpn/provider/aws"
+	"github.com/dan-v/dosxvpn/provider/digitalocean"
+	"github.com/dan-v/dosxvpn/provider/linode"
 	"github.com/dan-v/dosxvpn/services"
+	"github.com/dan-v/dosxvpn/services/adguard"
 	"github.com/dan-v/dosxvpn/services/coreos"
 	"github.com/dan-v/dosxvpn/services/dosxvpn"
 	"github.com/dan-v/dosxvpn/services/pihole"
+	"github.com/dan-v/dosxvpn/services/wireguard"
 	"github.com/dan-v/dosxvpn/sshclient"
 	"github.com/dan-v/dosxvpn/vpn"
 )
 
 const (
 	DropletBaseName = "dosxvpn"
-	DropletImage    = "coreos-beta"
-	DropletSize     = "512mb"
+
+	// DropletImage and DropletSize are DigitalOcean-only defaults, applied
+	// by New when Provider is ProviderDigitalOcean. They don't carry over
+	// to the other providers: EC2 AMI IDs are region-specific and Linode
+	// image/type slugs use a different naming scheme entirely, so callers
+	// must set Deployment.Size/Image themselves for "aws"/"linode" - Run
+	// returns an error if either is still empty. Whatever image a caller
+	// picks for those providers must still be CoreOS/Flatcar (or
+	// otherwise ship coreos-cloudinit), since the cloud-init userdata the
+	// services package generates relies on the `coreos:` unit section.
+	DropletImage = "coreos-beta"
+	DropletSize  = "512mb"
+
+	// ProviderDigitalOcean, ProviderLinode and ProviderAWS are the values
+	// newProvider (and Deployment.Provider) accept.
+	ProviderDigitalOcean = "digitalocean"
+	ProviderLinode       = "linode"
+	ProviderAWS          = "aws"
+
+	// DefaultProvider is used when Deployment.Provider is left blank.
+	DefaultProvider = ProviderDigitalOcean
+
+	// ProtocolIPsec and ProtocolWireguard are the supported values for
+	// Deployment.Protocol. An empty Protocol is treated as ProtocolIPsec.
+	ProtocolIPsec     = "ipsec"
+	ProtocolWireguard = "wireguard"
+
+	// DNSFilterNone, DNSFilterPihole and DNSFilterAdguard are the supported
+	// values for Deployment.DNSFilter. An empty DNSFilter is treated as
+	// DefaultDNSFilter.
+	DNSFilterNone    = "none"
+	DNSFilterPihole  = "pihole"
+	DNSFilterAdguard = "adguard"
+
+	// DefaultDNSFilter is used when Deployment.DNSFilter is left blank.
+	DefaultDNSFilter = DNSFilterPihole
+
+	// IPsecVPNSubnet is the rightsourceip pool strongswan assigns IPsec
+	// clients from, used to firewall the AdGuard admin UI off from the
+	// public internet when Protocol is ProtocolIPsec.
+	IPsecVPNSubnet = "10.10.10.0/24"
+
+	// DefaultRetryTimeout and DefaultRetrySleep are used when Deployment
+	// doesn't set RetryTimeout/RetrySleep explicitly.
+	DefaultRetryTimeout = 5 * time.Minute
+	DefaultRetrySleep   = 10 * time.Second
+
+	// Stage values track how far Run got, so Resume knows where to pick
+	// back up and Rollback knows what it's safe to tear down.
+	StagePending         = "pending"
+	StageInstanceCreated = "instance_created"
+	StageHasIP           = "has_ip"
+	StageFirewallCreated = "firewall_created"
+	StageSSHReady        = "ssh_ready"
+	StageVPNActive       = "vpn_active"
+	StageCertsFetched    = "certs_fetched"
+	StageDone            = "done"
 )
 
 var (
@@ -38,6 +99,9 @@ var (
 	FilenamePrivateKey       = "%s.client.cert.p12"
 	FilenameCACert           = "%s.ca.cert.pem"
 	FilenameServerCert       = "%s.server.cert.pem"
+	FilenameWireguardConfig  = "%s.wireguard.conf"
+	FilenameWireguardQR      = "%s.wireguard.png"
+	FilenameWindowsScript    = "%s.windows.ps1"
 	VpnFiles                 = map[string]string{
 		"/etc/ipsec.d/client.cert.p12":       FilenamePrivateKey,
 		"/etc/ipsec.d/cacerts/ca.cert.pem":   FilenameCACert,
@@ -46,36 +110,117 @@ var (
 )
 
 type Deployment struct {
-	Region          string
-	AutoConfigure   bool
-	Name            string
-	Token           string
-	services        []services.Service
-	userData        string
-	dropletIP       string
-	dropletID       int
-	doClient        *doclient.Client
-	sshClient       *sshclient.Client
-	VpnPassword     string
-	Status          string `json:"status"`
-	VPNIPAddress    string `json:"ip_address"`
-	InitialPublicIP string `json:"initial_ip"`
-	FinalPublicIP   string `json:"final_ip"`
+	Provider         string
+	Protocol         string
+	DNSFilter        string
+	Region           string
+	Size             string
+	Image            string
+	AutoConfigure    bool
+	Name             string
+	Token            string
+	services         []services.Service
+	WireguardService *wireguard.Service `json:"wireguard,omitempty"`
+	AdguardService   *adguard.Service   `json:"adguard,omitempty"`
+	userData         string
+	dropletIP        string
+	provider         provider.Provider
+	sshClient        *sshclient.Client
+	VpnPassword      string
+	RetryTimeout     time.Duration `json:"-"`
+	RetrySleep       time.Duration `json:"-"`
+	Stage            string        `json:"stage"`
+	InstanceID       string        `json:"instance_id"`
+	FirewallID       string        `json:"firewall_id"`
+	Status           string        `json:"status"`
+	VPNIPAddress     string        `json:"ip_address"`
+	InitialPublicIP  string        `json:"initial_ip"`
+	FinalPublicIP    string        `json:"final_ip"`
+
+	// Logger receives the progress messages Run used to print via the
+	// package-level log. Defaults to a logger on os.Stderr.
+	Logger *log.Logger `json:"-"`
+
+	// PreCreate/PostCreate bracket instance creation; PreUp/PostUp bracket
+	// the whole Run. OnStatusChange fires every time Status changes. These
+	// let an embedder react to stage transitions and plug in its own
+	// logging instead of scraping Status or package-level log output.
+	PreCreate      func() error                  `json:"-"`
+	PostCreate     func(instanceID string) error `json:"-"`
+	PreUp          func() error                  `json:"-"`
+	PostUp         func() error                  `json:"-"`
+	OnStatusChange func(status string)           `json:"-"`
 }
 
-func New(token, region string, autoConfigure bool) (*Deployment, error) {
+// New creates a Deployment that will provision against providerName
+// ("digitalocean", "linode" or "aws") using the given VPN protocol
+// ("ipsec" or "wireguard") and DNS filter ("none", "pihole" or "adguard").
+// Empty values fall back to DefaultProvider, ProtocolIPsec and
+// DefaultDNSFilter respectively.
+func New(providerName, protocol, token, region, dnsFilter string, autoConfigure bool) (*Deployment, error) {
+	if providerName == "" {
+		providerName = DefaultProvider
+	}
+	if protocol == "" {
+		protocol = ProtocolIPsec
+	}
+	if dnsFilter == "" {
+		dnsFilter = DefaultDNSFilter
+	}
+	cloudProvider, err := newProvider(providerName, token, region)
+	if err != nil {
+		return nil, err
+	}
+
 	deploy := &Deployment{
 		Name:          DropletBaseName + "-" + randomString(3) + "-" + region,
-		Token:         token,
+		Provider:      providerName,
+		Protocol:      protocol,
+		DNSFilter:     dnsFilter,
 		Region:        region,
+		Token:         token,
 		AutoConfigure: autoConfigure,
-		doClient:      doclient.New(token),
-		services: []services.Service{
-			&coreos.Service{}, &dosxvpn.Service{}, &pihole.Service{},
-		},
-		Status: "pending auth",
+		provider:      cloudProvider,
+		RetryTimeout:  DefaultRetryTimeout,
+		RetrySleep:    DefaultRetrySleep,
+		Stage:         StagePending,
+		Status:        "pending auth",
+		Logger:        log.New(os.Stderr, "", log.LstdFlags),
 	}
-	var err error
+	if providerName == ProviderDigitalOcean {
+		deploy.Size = DropletSize
+		deploy.Image = DropletImage
+	}
+
+	vpnSubnet := IPsecVPNSubnet
+	switch protocol {
+	case ProtocolWireguard:
+		deploy.WireguardService, err = wireguard.New()
+		if err != nil {
+			return nil, err
+		}
+		vpnSubnet = wireguard.ServerSubnet
+		deploy.services = []services.Service{
+			&coreos.Service{}, deploy.WireguardService,
+		}
+	default:
+		deploy.services = []services.Service{
+			&coreos.Service{}, &dosxvpn.Service{},
+		}
+	}
+
+	switch dnsFilter {
+	case DNSFilterNone:
+	case DNSFilterAdguard:
+		deploy.AdguardService, err = adguard.New(vpnSubnet)
+		if err != nil {
+			return nil, err
+		}
+		deploy.services = append(deploy.services, deploy.AdguardService)
+	default:
+		deploy.services = append(deploy.services, &pihole.Service{})
+	}
+
 	deploy.sshClient, err = sshclient.New()
 	if err != nil {
 		return nil, err
@@ -87,100 +232,386 @@ func New(token, region string, autoConfigure bool) (*Deployment, error) {
 	return deploy, nil
 }
 
-func (d *Deployment) Run() error {
-	log.Println("Getting initial IP...")
-	initialPublicIP, _ := getPublicIp()
-	d.InitialPublicIP = initialPublicIP
-	log.Println("Initial IP is", d.InitialPublicIP)
+// newProvider resolves a provider name to a provider.Provider implementation.
+func newProvider(providerName, token, region string) (provider.Provider, error) {
+	switch providerName {
+	case ProviderDigitalOcean:
+		return digitalocean.New(token), nil
+	case ProviderLinode:
+		return linode.New(token), nil
+	case ProviderAWS:
+		return aws.New(region)
+	default:
+		return nil, fmt.Errorf("unknown provider %q", providerName)
+	}
+}
 
-	log.Println("Creating droplet...")
-	dropletID, err := d.doClient.CreateDroplet(d.Name, d.Region, DropletSize, d.userData, DropletImage)
-	if err != nil {
-		log.Fatal(err)
+// retry calls fn until it succeeds, sleeping interval between attempts, and
+// gives up once timeout has elapsed since the first attempt.
+func retry(timeout, interval time.Duration, fn func() error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return err
+		}
+		time.Sleep(interval)
 	}
-	d.dropletID = dropletID
-	log.Printf("Finished creating droplet %s", d.Name)
+}
 
-	log.Println("Waiting for droplet to get IP...")
-	dropletIP, err := d.doClient.WaitForDropletIP(d.dropletID)
+func statePath(name string) string {
+	return filepath.Join(FilepathDosxvpnConfigDir, name+".state.json")
+}
+
+// persistState writes the Deployment's exported fields to
+// FilepathDosxvpnConfigDir/<name>.state.json so Resume/Rollback can pick it
+// back up after the process dies mid-deployment.
+func (d *Deployment) persistState() error {
+	data, err := json.MarshalIndent(d, "", "  ")
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	d.dropletIP = dropletIP
-	log.Printf("Droplet now has IP %s...", d.dropletIP)
-	d.VPNIPAddress = d.dropletIP
+	os.MkdirAll(FilepathDosxvpnConfigDir, os.ModePerm)
+	return ioutil.WriteFile(statePath(d.Name), data, 0600)
+}
 
-	log.Println("Creating firewall...")
-	err = d.doClient.CreateFirewall(d.Name, d.dropletID)
+// Resume reloads the state persisted by a previous Run and returns a
+// Deployment ready to have Run called again, continuing from the last
+// completed stage instead of starting over.
+func Resume(name string) (*Deployment, error) {
+	data, err := ioutil.ReadFile(statePath(name))
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	d := &Deployment{}
+	if err := json.Unmarshal(data, d); err != nil {
+		return nil, err
 	}
-	log.Println("Finished creating firewall...")
 
-	d.Status = "waiting for ssh"
-	log.Println("Waiting for SSH to start...")
-	err = waitForSSH(d.dropletIP)
+	d.provider, err = newProvider(d.Provider, d.Token, d.Region)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
-	log.Println("SSH is now online...")
-
-	log.Println("Waiting for VPN to become active...")
-	_, err = d.sshClient.Run("core", d.dropletIP, "until docker logs dosxvpn &>/dev/null; do sleep 2; done; sleep 5;")
+	d.sshClient, err = sshclient.New()
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
+	}
+	if d.Protocol == ProtocolWireguard && d.WireguardService == nil {
+		d.WireguardService, err = wireguard.New()
+		if err != nil {
+			return nil, err
+		}
 	}
-	log.Println("VPN is now active...")
+	if d.RetryTimeout == 0 {
+		d.RetryTimeout = DefaultRetryTimeout
+	}
+	if d.RetrySleep == 0 {
+		d.RetrySleep = DefaultRetrySleep
+	}
+	if d.Provider == ProviderDigitalOcean {
+		if d.Size == "" {
+			d.Size = DropletSize
+		}
+		if d.Image == "" {
+			d.Image = DropletImage
+		}
+	}
+	d.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	d.dropletIP = d.VPNIPAddress
 
-	log.Println("Getting/generating VPN files...")
-	privateKeyPasswordString, err := d.sshClient.GetFileFromContainer("core", d.dropletIP, "dosxvpn", "/etc/ipsec.d/client.cert.p12.password")
+	return d, nil
+}
+
+// Rollback tears down the instance (and firewall) left behind by a
+// deployment named name that failed partway through, using the instance ID
+// recorded in its persisted state, then removes that state file.
+func Rollback(name string) error {
+	d, err := Resume(name)
 	if err != nil {
 		return err
 	}
-	d.VpnPassword = privateKeyPasswordString
 
-	privateKeyString, err := d.sshClient.GetFileFromContainer("core", d.dropletIP, "dosxvpn", "/etc/ipsec.d/client.cert.p12")
-	if err != nil {
-		return err
+	if d.InstanceID != "" {
+		if err := d.provider.DestroyInstance(d.InstanceID, d.FirewallID); err != nil {
+			return err
+		}
 	}
-	saveConfig(privateKeyString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenamePrivateKey, d.Name)))
 
-	caCertString, err := d.sshClient.GetFileFromContainer("core", d.dropletIP, "dosxvpn", "/etc/ipsec.d/cacerts/ca.cert.pem")
-	if err != nil {
-		return err
+	return os.Remove(statePath(name))
+}
+
+func (d *Deployment) logln(args ...interface{}) {
+	d.Logger.Println(args...)
+}
+
+func (d *Deployment) logf(format string, args ...interface{}) {
+	d.Logger.Printf(format, args...)
+}
+
+// setStatus updates Status and notifies OnStatusChange, if set.
+func (d *Deployment) setStatus(status string) {
+	d.Status = status
+	if d.OnStatusChange != nil {
+		d.OnStatusChange(status)
 	}
-	saveConfig(caCertString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameCACert, d.Name)))
+}
 
-	serverCertString, err := d.sshClient.GetFileFromContainer("core", d.dropletIP, "dosxvpn", "/etc/ipsec.d/certs/server.cert.pem")
-	if err != nil {
-		return err
+// Run provisions the deployment, persisting Deployment state to
+// FilepathDosxvpnConfigDir/<name>.state.json after every stage. A transient
+// failure within a stage is retried (RetryTimeout/RetrySleep) instead of
+// killing the process via log.Fatal, which used to leave a half-created
+// droplet with no way to continue or clean it up. Calling Run again after a
+// partial failure (or via Resume) picks up at d.Stage instead of redoing
+// completed stages.
+func (d *Deployment) Run() error {
+	var err error
+	if d.Logger == nil {
+		d.Logger = log.New(os.Stderr, "", log.LstdFlags)
+	}
+	if d.Stage == "" {
+		d.Stage = StagePending
+	}
+	if d.Size == "" || d.Image == "" {
+		return fmt.Errorf("deploy: Size and Image must be set for provider %q; only %q has built-in defaults", d.Provider, ProviderDigitalOcean)
 	}
-	saveConfig(serverCertString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameServerCert, d.Name)))
 
-	appleConfigString, err := genconfig.GenerateAppleConfig(d.dropletIP, d.Name, privateKeyPasswordString, privateKeyString, caCertString, serverCertString)
-	if err != nil {
-		return err
+	if d.PreUp != nil {
+		if err := d.PreUp(); err != nil {
+			return err
+		}
 	}
-	saveConfig(appleConfigString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameAppleConfig, d.Name)))
 
-	androidConfigString, err := genconfig.GenerateAndroidConfig(d.dropletIP, d.Name, privateKeyString, caCertString)
-	if err != nil {
-		return err
+	if d.Stage == StagePending {
+		d.logln("Getting initial IP...")
+		initialPublicIP, _ := getPublicIp()
+		d.InitialPublicIP = initialPublicIP
+		d.logln("Initial IP is", d.InitialPublicIP)
+
+		if d.PreCreate != nil {
+			if err := d.PreCreate(); err != nil {
+				return err
+			}
+		}
+
+		d.logln("Creating droplet...")
+		var instanceID string
+		err = retry(d.RetryTimeout, d.RetrySleep, func() error {
+			// Check for an instance already created under this name before
+			// creating another one, so a transient error after a create
+			// that actually succeeded (e.g. a flaky response read) picks
+			// up the existing instance on retry instead of spawning a
+			// second, untracked one.
+			existingID, findErr := d.provider.FindInstance(d.Name)
+			if findErr != nil {
+				return findErr
+			}
+			if existingID != "" {
+				instanceID = existingID
+				return nil
+			}
+			var createErr error
+			instanceID, createErr = d.provider.CreateInstance(d.Name, d.Region, d.Size, d.userData, d.Image)
+			return createErr
+		})
+		if err != nil {
+			return err
+		}
+		d.InstanceID = instanceID
+		d.logf("Finished creating droplet %s", d.Name)
+
+		if d.PostCreate != nil {
+			if err := d.PostCreate(instanceID); err != nil {
+				return err
+			}
+		}
+
+		d.Stage = StageInstanceCreated
+		if err := d.persistState(); err != nil {
+			return err
+		}
+	}
+
+	if d.Stage == StageInstanceCreated {
+		d.logln("Waiting for droplet to get IP...")
+		err = retry(d.RetryTimeout, d.RetrySleep, func() error {
+			dropletIP, waitErr := d.provider.WaitForIP(d.InstanceID)
+			if waitErr != nil {
+				return waitErr
+			}
+			d.dropletIP = dropletIP
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		d.logf("Droplet now has IP %s...", d.dropletIP)
+		d.VPNIPAddress = d.dropletIP
+
+		d.Stage = StageHasIP
+		if err := d.persistState(); err != nil {
+			return err
+		}
+	}
+	if d.dropletIP == "" {
+		d.dropletIP = d.VPNIPAddress
+	}
+
+	if d.Stage == StageHasIP {
+		d.logln("Creating firewall...")
+		err = retry(d.RetryTimeout, d.RetrySleep, func() error {
+			firewallID, createErr := d.provider.CreateFirewall(d.Name, d.InstanceID, d.Protocol)
+			if createErr != nil {
+				return createErr
+			}
+			d.FirewallID = firewallID
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		d.logln("Finished creating firewall...")
+
+		d.Stage = StageFirewallCreated
+		if err := d.persistState(); err != nil {
+			return err
+		}
 	}
-	saveConfig(androidConfigString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameAndroidConfig, d.Name)))
 
-	log.Println("Finished getting/generating VPN files...")
+	if d.Stage == StageFirewallCreated {
+		d.setStatus("waiting for ssh")
+		d.logln("Waiting for SSH to start...")
+		err = retry(d.RetryTimeout, d.RetrySleep, func() error {
+			return waitForSSH(d.dropletIP)
+		})
+		if err != nil {
+			return err
+		}
+		d.logln("SSH is now online...")
+
+		d.Stage = StageSSHReady
+		if err := d.persistState(); err != nil {
+			return err
+		}
+	}
+
+	vpnContainer := "dosxvpn"
+	if d.Protocol == ProtocolWireguard {
+		vpnContainer = "wireguard"
+	}
+
+	if d.Stage == StageSSHReady {
+		d.logln("Waiting for VPN to become active...")
+		err = retry(d.RetryTimeout, d.RetrySleep, func() error {
+			_, runErr := d.sshClient.Run("core", d.dropletIP, fmt.Sprintf("until docker logs %s &>/dev/null; do sleep 2; done; sleep 5;", vpnContainer))
+			return runErr
+		})
+		if err != nil {
+			return err
+		}
+		d.logln("VPN is now active...")
+
+		d.Stage = StageVPNActive
+		if err := d.persistState(); err != nil {
+			return err
+		}
+	}
+
+	var privateKeyPasswordString string
+	if d.Stage == StageVPNActive {
+		d.logln("Getting/generating VPN files...")
+
+		err = retry(d.RetryTimeout, d.RetrySleep, func() error {
+			if d.Protocol == ProtocolWireguard {
+				peerConfigString := d.WireguardService.PeerConfig(d.dropletIP)
+				saveConfig(peerConfigString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameWireguardConfig, d.Name)))
+
+				peerConfigQR, qrErr := genconfig.GenerateWireguardQR(peerConfigString)
+				if qrErr != nil {
+					return qrErr
+				}
+				saveConfig(string(peerConfigQR), filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameWireguardQR, d.Name)))
+				return nil
+			}
+
+			privateKeyPasswordString, err = d.sshClient.GetFileFromContainer("core", d.dropletIP, "dosxvpn", "/etc/ipsec.d/client.cert.p12.password")
+			if err != nil {
+				return err
+			}
+			d.VpnPassword = privateKeyPasswordString
+
+			privateKeyString, err := d.sshClient.GetFileFromContainer("core", d.dropletIP, "dosxvpn", "/etc/ipsec.d/client.cert.p12")
+			if err != nil {
+				return err
+			}
+			saveConfig(privateKeyString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenamePrivateKey, d.Name)))
+
+			caCertString, err := d.sshClient.GetFileFromContainer("core", d.dropletIP, "dosxvpn", "/etc/ipsec.d/cacerts/ca.cert.pem")
+			if err != nil {
+				return err
+			}
+			saveConfig(caCertString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameCACert, d.Name)))
+
+			serverCertString, err := d.sshClient.GetFileFromContainer("core", d.dropletIP, "dosxvpn", "/etc/ipsec.d/certs/server.cert.pem")
+			if err != nil {
+				return err
+			}
+			saveConfig(serverCertString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameServerCert, d.Name)))
+
+			appleConfigString, err := genconfig.GenerateAppleConfig(d.dropletIP, d.Name, privateKeyPasswordString, privateKeyString, caCertString, serverCertString)
+			if err != nil {
+				return err
+			}
+			saveConfig(appleConfigString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameAppleConfig, d.Name)))
+
+			androidConfigString, err := genconfig.GenerateAndroidConfig(d.dropletIP, d.Name, privateKeyString, caCertString)
+			if err != nil {
+				return err
+			}
+			saveConfig(androidConfigString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameAndroidConfig, d.Name)))
+
+			windowsScriptString, err := genconfig.GenerateWindowsScript(d.dropletIP, d.Name)
+			if err != nil {
+				return err
+			}
+			saveConfig(windowsScriptString, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameWindowsScript, d.Name)))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		d.logln("Finished getting/generating VPN files...")
+
+		d.Stage = StageCertsFetched
+		if err := d.persistState(); err != nil {
+			return err
+		}
+	}
+
+	initialPublicIP := d.InitialPublicIP
 
 	if d.AutoConfigure {
-		d.Status = "adding vpn to osx"
-		log.Println("Adding VPN to OSX...")
-		err = vpn.OSXAddVPN(filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameAppleConfig, d.Name)))
+		d.setStatus("adding vpn")
+		d.logln("Adding VPN to this machine...")
+		switch {
+		case d.Protocol == ProtocolWireguard:
+			err = vpn.OSXAddWireguard(filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameWireguardConfig, d.Name)))
+		case runtime.GOOS == "windows":
+			err = vpn.WindowsAddVPN(filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameWindowsScript, d.Name)))
+		case runtime.GOOS == "linux":
+			err = vpn.LinuxAddVPN(d.Name, d.dropletIP, filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameCACert, d.Name)))
+		default:
+			err = vpn.OSXAddVPN(filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameAppleConfig, d.Name)))
+		}
 		if err != nil {
-			log.Println("Failed to add VPN to OSX.", err)
+			d.logln("Failed to add VPN.", err)
 		}
-		log.Println("Done Adding VPN to OSX...")
+		d.logln("Done adding VPN...")
 
-		d.Status = "waiting for ip address change"
+		d.setStatus("waiting for ip address change")
 		for j := 0; j < 10; j++ {
 			time.Sleep(time.Second * 5)
 			newIP, err := getPublicIp()
@@ -190,18 +621,40 @@ func (d *Deployment) Run() error {
 				break
 			}
 		}
-		d.Status = "done"
+		d.setStatus("done")
+	}
+
+	d.logln("##############################")
+	d.logln("VPN IP:", d.dropletIP)
+	if d.Protocol == ProtocolWireguard {
+		d.logln("Wireguard Config:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameWireguardConfig, d.Name)))
+		d.logln("Wireguard QR Code:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameWireguardQR, d.Name)))
+	} else {
+		d.logln("Apple Config:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameAppleConfig, d.Name)))
+		d.logln("Android Config:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameAndroidConfig, d.Name)))
+		d.logln("Windows Script:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameWindowsScript, d.Name)))
+		d.logln("Client Private Key:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenamePrivateKey, d.Name)))
+		d.logln("Client Private Key Passphrase:", d.VpnPassword)
+		d.logln("CA Certificate:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameCACert, d.Name)))
+		d.logln("Server Certificate:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameServerCert, d.Name)))
+	}
+	if d.DNSFilter == DNSFilterAdguard {
+		d.logln("AdGuard Admin URL:", fmt.Sprintf("http://%s:%d", d.dropletIP, adguard.WebPort))
+		d.logln("AdGuard Admin User:", adguard.AdminUser)
+		d.logln("AdGuard Admin Password:", d.AdguardService.AdminPassword)
+	}
+	d.logln("##############################")
+
+	d.Stage = StageDone
+	if err := d.persistState(); err != nil {
+		return err
 	}
 
-	log.Println("##############################")
-	log.Println("VPN IP:", d.dropletIP)
-	log.Println("Apple Config:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameAppleConfig, d.Name)))
-	log.Println("Android Config:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameAndroidConfig, d.Name)))
-	log.Println("Client Private Key:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenamePrivateKey, d.Name)))
-	log.Println("Client Private Key Passphrase:", privateKeyPasswordString)
-	log.Println("CA Certificate:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameCACert, d.Name)))
-	log.Println("Server Certificate:", filepath.Join(FilepathDosxvpnConfigDir, fmt.Sprintf(FilenameServerCert, d.Name)))
-	log.Println("##############################")
+	if d.PostUp != nil {
+		if err := d.PostUp(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
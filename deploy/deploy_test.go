@@ -0,0 +1,84 @@
+package deploy
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRetrySucceedsWithinTimeout(t *testing.T) {
+	attempts := 0
+	err := retry(time.Second, time.Millisecond, func() error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("fn ran %d times, want 3", attempts)
+	}
+}
+
+func TestRetryGivesUpAfterTimeout(t *testing.T) {
+	wantErr := errors.New("still failing")
+	err := retry(20*time.Millisecond, 5*time.Millisecond, func() error {
+		return wantErr
+	})
+	if err != wantErr {
+		t.Fatalf("retry returned %v, want %v", err, wantErr)
+	}
+}
+
+func TestResumeRoundTripsPersistedState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dosxvpn-deploy-test")
+	if err != nil {
+		t.Fatalf("TempDir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	origConfigDir := FilepathDosxvpnConfigDir
+	FilepathDosxvpnConfigDir = dir
+	defer func() { FilepathDosxvpnConfigDir = origConfigDir }()
+
+	d, err := New(ProviderDigitalOcean, ProtocolIPsec, "token", "nyc3", DNSFilterNone, false)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	d.Stage = StageHasIP
+	d.InstanceID = "12345"
+	d.FirewallID = "fw-1"
+	d.VPNIPAddress = "203.0.113.5"
+	if err := d.persistState(); err != nil {
+		t.Fatalf("persistState: %v", err)
+	}
+
+	resumed, err := Resume(d.Name)
+	if err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+
+	if resumed.Stage != d.Stage {
+		t.Errorf("Stage = %q, want %q", resumed.Stage, d.Stage)
+	}
+	if resumed.InstanceID != d.InstanceID {
+		t.Errorf("InstanceID = %q, want %q", resumed.InstanceID, d.InstanceID)
+	}
+	if resumed.FirewallID != d.FirewallID {
+		t.Errorf("FirewallID = %q, want %q", resumed.FirewallID, d.FirewallID)
+	}
+	if resumed.VPNIPAddress != d.VPNIPAddress {
+		t.Errorf("VPNIPAddress = %q, want %q", resumed.VPNIPAddress, d.VPNIPAddress)
+	}
+	if resumed.Size != DropletSize || resumed.Image != DropletImage {
+		t.Errorf("Size/Image = %q/%q, want DigitalOcean defaults restored", resumed.Size, resumed.Image)
+	}
+	if resumed.RetryTimeout != DefaultRetryTimeout || resumed.RetrySleep != DefaultRetrySleep {
+		t.Errorf("RetryTimeout/RetrySleep weren't defaulted on resume")
+	}
+}
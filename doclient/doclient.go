@@ -0,0 +1,207 @@
+// Package doclient is a small client for the DigitalOcean API v2, covering
+// only what provider/digitalocean needs to create, locate and tear down a
+// droplet and the firewall protecting it.
+package doclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const apiBase = "https://api.digitalocean.com/v2"
+
+// Client is a thin wrapper around the DigitalOcean API v2, authenticated
+// with a personal access token.
+type Client struct {
+	token      string
+	httpClient *http.Client
+}
+
+// New returns a Client authenticated with a personal access token.
+func New(token string) *Client {
+	return &Client{
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type droplet struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Networks struct {
+		V4 []struct {
+			IPAddress string `json:"ip_address"`
+			Type      string `json:"type"`
+		} `json:"v4"`
+	} `json:"networks"`
+}
+
+// CreateDroplet boots a new droplet running userData as cloud-init and
+// returns its ID.
+func (c *Client) CreateDroplet(name, region, size, userData, image string) (int, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"name":      name,
+		"region":    region,
+		"size":      size,
+		"image":     image,
+		"user_data": userData,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var out struct {
+		Droplet droplet `json:"droplet"`
+	}
+	if err := c.do("POST", "/droplets", body, &out); err != nil {
+		return 0, err
+	}
+	return out.Droplet.ID, nil
+}
+
+// WaitForDropletIP blocks until dropletID has a public IPv4 address and
+// returns it.
+func (c *Client) WaitForDropletIP(dropletID int) (string, error) {
+	for attempt := 0; attempt < 60; attempt++ {
+		var out struct {
+			Droplet droplet `json:"droplet"`
+		}
+		if err := c.do("GET", fmt.Sprintf("/droplets/%d", dropletID), nil, &out); err != nil {
+			return "", err
+		}
+		for _, network := range out.Droplet.Networks.V4 {
+			if network.Type == "public" && network.IPAddress != "" {
+				return network.IPAddress, nil
+			}
+		}
+		time.Sleep(5 * time.Second)
+	}
+	return "", fmt.Errorf("timed out waiting for droplet %d to get an IP", dropletID)
+}
+
+// FindDropletByName returns the ID of the droplet named name, or 0 if none
+// exists. Used to recover from a CreateDroplet call that actually
+// succeeded but returned an error before it could report the new ID.
+func (c *Client) FindDropletByName(name string) (int, error) {
+	var out struct {
+		Droplets []droplet `json:"droplets"`
+	}
+	if err := c.do("GET", "/droplets?per_page=200", nil, &out); err != nil {
+		return 0, err
+	}
+	for _, d := range out.Droplets {
+		if d.Name == name {
+			return d.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// CreateFirewall restricts dropletID to the ports dosxvpn needs and returns
+// the firewall's ID (a UUID string, unlike the int droplet IDs this client
+// otherwise uses).
+func (c *Client) CreateFirewall(name string, dropletID int) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"name": name + "-fw",
+		"inbound_rules": []map[string]interface{}{
+			{"protocol": "tcp", "ports": "22", "sources": map[string]interface{}{"addresses": []string{"0.0.0.0/0", "::/0"}}},
+			{"protocol": "udp", "ports": "500", "sources": map[string]interface{}{"addresses": []string{"0.0.0.0/0", "::/0"}}},
+			{"protocol": "udp", "ports": "4500", "sources": map[string]interface{}{"addresses": []string{"0.0.0.0/0", "::/0"}}},
+			{"protocol": "udp", "ports": "51820", "sources": map[string]interface{}{"addresses": []string{"0.0.0.0/0", "::/0"}}},
+		},
+		"outbound_rules": []map[string]interface{}{
+			{"protocol": "tcp", "ports": "all", "destinations": map[string]interface{}{"addresses": []string{"0.0.0.0/0", "::/0"}}},
+			{"protocol": "udp", "ports": "all", "destinations": map[string]interface{}{"addresses": []string{"0.0.0.0/0", "::/0"}}},
+		},
+		"droplet_ids": []int{dropletID},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var out struct {
+		Firewall struct {
+			ID string `json:"id"`
+		} `json:"firewall"`
+	}
+	if err := c.do("POST", "/firewalls", body, &out); err != nil {
+		return "", err
+	}
+	return out.Firewall.ID, nil
+}
+
+// DestroyDroplet deletes dropletID.
+func (c *Client) DestroyDroplet(dropletID int) error {
+	return c.do("DELETE", fmt.Sprintf("/droplets/%d", dropletID), nil, nil)
+}
+
+// DestroyFirewall deletes the firewall identified by firewallID, the UUID
+// string CreateFirewall returned.
+func (c *Client) DestroyFirewall(firewallID string) error {
+	return c.do("DELETE", "/firewalls/"+firewallID, nil, nil)
+}
+
+// ListRegions returns the region slugs this account can create droplets in.
+func (c *Client) ListRegions() ([]string, error) {
+	var out struct {
+		Regions []struct {
+			Slug string `json:"slug"`
+		} `json:"regions"`
+	}
+	if err := c.do("GET", "/regions", nil, &out); err != nil {
+		return nil, err
+	}
+	regions := make([]string, 0, len(out.Regions))
+	for _, r := range out.Regions {
+		regions = append(regions, r.Slug)
+	}
+	return regions, nil
+}
+
+// ListSizes returns the droplet size slugs this account can use.
+func (c *Client) ListSizes() ([]string, error) {
+	var out struct {
+		Sizes []struct {
+			Slug string `json:"slug"`
+		} `json:"sizes"`
+	}
+	if err := c.do("GET", "/sizes", nil, &out); err != nil {
+		return nil, err
+	}
+	sizes := make([]string, 0, len(out.Sizes))
+	for _, s := range out.Sizes {
+		sizes = append(sizes, s.Slug)
+	}
+	return sizes, nil
+}
+
+func (c *Client) do(method, path string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, apiBase+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("digitalocean API %s %s: %d: %s", method, path, resp.StatusCode, string(respBody))
+	}
+	if out != nil {
+		return json.Unmarshal(respBody, out)
+	}
+	return nil
+}
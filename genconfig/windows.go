@@ -0,0 +1,12 @@
+package genconfig
+
+import "fmt"
+
+// GenerateWindowsScript renders a PowerShell script that installs the same
+// IKEv2 profile as vpn.WindowsAddVPN, for users who'd rather install it
+// themselves than have dosxvpn configure the VPN automatically.
+func GenerateWindowsScript(dropletIP, name string) (string, error) {
+	return fmt.Sprintf(`Add-VpnConnection -Name '%s' -ServerAddress '%s' -TunnelType IKEv2 -AuthenticationMethod MachineCertificate -EncryptionLevel Required -RememberCredential -Force
+Set-VpnConnectionIPsecConfiguration -ConnectionName '%s' -AuthenticationTransformConstants GCMAES256 -CipherTransformConstants GCMAES256 -EncryptionMethod GCMAES256 -IntegrityCheckMethod SHA256 -PfsGroup PFS2048 -DHGroup Group14 -Force
+`, name, dropletIP, name), nil
+}
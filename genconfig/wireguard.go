@@ -0,0 +1,11 @@
+package genconfig
+
+import "github.com/skip2/go-qrcode"
+
+// GenerateWireguardQR renders peerConfig (a wg0.conf) as a PNG QR code so it
+// can be scanned straight into the WireGuard mobile app, the same way
+// GenerateAppleConfig/GenerateAndroidConfig produce installable bundles for
+// their platforms.
+func GenerateWireguardQR(peerConfig string) ([]byte, error) {
+	return qrcode.Encode(peerConfig, qrcode.Medium, 512)
+}
@@ -0,0 +1,206 @@
+// Package aws implements provider.Provider against EC2, giving dosxvpn a
+// third cloud backend alongside DigitalOcean and Linode.
+package aws
+
+import (
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
+)
+
+// Provider is the AWS EC2 implementation of provider.Provider. Region is
+// fixed at construction time since an EC2 session is scoped to one region.
+type Provider struct {
+	svc *ec2.EC2
+}
+
+// New returns an EC2 Provider for region, using the default AWS credential
+// chain (env vars, shared config, instance role, ...).
+func New(region string) (*Provider, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(region)})
+	if err != nil {
+		return nil, err
+	}
+	return &Provider{svc: ec2.New(sess)}, nil
+}
+
+func (p *Provider) FindInstance(name string) (string, error) {
+	out, err := p.svc.DescribeInstances(&ec2.DescribeInstancesInput{
+		Filters: []*ec2.Filter{
+			{Name: aws.String("tag:Name"), Values: []*string{aws.String(name)}},
+			{Name: aws.String("instance-state-name"), Values: []*string{aws.String("pending"), aws.String("running")}},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	for _, reservation := range out.Reservations {
+		for _, instance := range reservation.Instances {
+			return *instance.InstanceId, nil
+		}
+	}
+	return "", nil
+}
+
+// CreateInstance boots a new instance tagged Name: name. ClientToken is
+// derived deterministically from name so that retrying CreateInstance after
+// a transient error (e.g. the request actually succeeded but the response
+// never arrived) is idempotent at the API level: DescribeInstances is
+// eventually consistent and FindInstance alone can't be trusted to see an
+// instance that was just created, but RunInstances itself de-duplicates on
+// ClientToken regardless of how fast the describe side catches up.
+func (p *Provider) CreateInstance(name, region, size, userData, image string) (string, error) {
+	out, err := p.svc.RunInstances(&ec2.RunInstancesInput{
+		ImageId:      aws.String(image),
+		InstanceType: aws.String(size),
+		MinCount:     aws.Int64(1),
+		MaxCount:     aws.Int64(1),
+		UserData:     aws.String(base64.StdEncoding.EncodeToString([]byte(userData))),
+		ClientToken:  aws.String(name),
+		TagSpecifications: []*ec2.TagSpecification{
+			{
+				ResourceType: aws.String("instance"),
+				Tags:         []*ec2.Tag{{Key: aws.String("Name"), Value: aws.String(name)}},
+			},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(out.Instances) == 0 {
+		return "", fmt.Errorf("ec2 RunInstances returned no instances")
+	}
+	return *out.Instances[0].InstanceId, nil
+}
+
+func (p *Provider) WaitForIP(instanceID string) (string, error) {
+	for attempt := 0; attempt < 60; attempt++ {
+		out, err := p.svc.DescribeInstances(&ec2.DescribeInstancesInput{
+			InstanceIds: []*string{aws.String(instanceID)},
+		})
+		if err != nil {
+			return "", err
+		}
+		if len(out.Reservations) > 0 && len(out.Reservations[0].Instances) > 0 {
+			instance := out.Reservations[0].Instances[0]
+			if instance.PublicIpAddress != nil && *instance.PublicIpAddress != "" {
+				return *instance.PublicIpAddress, nil
+			}
+		}
+		time.Sleep(5 * time.Second)
+	}
+	return "", fmt.Errorf("timed out waiting for instance %s to get an IP", instanceID)
+}
+
+func (p *Provider) CreateFirewall(name, instanceID, protocol string) (string, error) {
+	group, err := p.svc.CreateSecurityGroup(&ec2.CreateSecurityGroupInput{
+		GroupName:   aws.String(name + "-sg"),
+		Description: aws.String("dosxvpn firewall for " + name),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	perms := []*ec2.IpPermission{
+		{
+			IpProtocol: aws.String("tcp"),
+			FromPort:   aws.Int64(22),
+			ToPort:     aws.Int64(22),
+			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+		},
+	}
+	if protocol == "wireguard" {
+		perms = append(perms, &ec2.IpPermission{
+			IpProtocol: aws.String("udp"),
+			FromPort:   aws.Int64(51820),
+			ToPort:     aws.Int64(51820),
+			IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+		})
+	} else {
+		perms = append(perms,
+			&ec2.IpPermission{
+				IpProtocol: aws.String("udp"),
+				FromPort:   aws.Int64(500),
+				ToPort:     aws.Int64(500),
+				IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+			},
+			&ec2.IpPermission{
+				IpProtocol: aws.String("udp"),
+				FromPort:   aws.Int64(4500),
+				ToPort:     aws.Int64(4500),
+				IpRanges:   []*ec2.IpRange{{CidrIp: aws.String("0.0.0.0/0")}},
+			},
+		)
+	}
+
+	_, err = p.svc.AuthorizeSecurityGroupIngress(&ec2.AuthorizeSecurityGroupIngressInput{
+		GroupId:       group.GroupId,
+		IpPermissions: perms,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	_, err = p.svc.ModifyInstanceAttribute(&ec2.ModifyInstanceAttributeInput{
+		InstanceId: aws.String(instanceID),
+		Groups:     []*string{group.GroupId},
+	})
+	if err != nil {
+		return "", err
+	}
+	return *group.GroupId, nil
+}
+
+// DestroyInstance terminates instanceID and, once it has fully shut down,
+// deletes the security group identified by firewallID. EC2 refuses to
+// delete a security group that's still attached to a running instance, so
+// the deletion waits on termination rather than racing it.
+func (p *Provider) DestroyInstance(instanceID, firewallID string) error {
+	_, err := p.svc.TerminateInstances(&ec2.TerminateInstancesInput{
+		InstanceIds: []*string{aws.String(instanceID)},
+	})
+	if err != nil {
+		return err
+	}
+	if firewallID == "" {
+		return nil
+	}
+
+	if err := p.svc.WaitUntilInstanceTerminated(&ec2.DescribeInstancesInput{
+		InstanceIds: []*string{aws.String(instanceID)},
+	}); err != nil {
+		return err
+	}
+	_, err = p.svc.DeleteSecurityGroup(&ec2.DeleteSecurityGroupInput{
+		GroupId: aws.String(firewallID),
+	})
+	return err
+}
+
+func (p *Provider) ListRegions() ([]string, error) {
+	out, err := p.svc.DescribeRegions(&ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, err
+	}
+	regions := make([]string, 0, len(out.Regions))
+	for _, r := range out.Regions {
+		regions = append(regions, *r.RegionName)
+	}
+	return regions, nil
+}
+
+func (p *Provider) ListSizes() ([]string, error) {
+	out, err := p.svc.DescribeInstanceTypes(&ec2.DescribeInstanceTypesInput{})
+	if err != nil {
+		return nil, err
+	}
+	sizes := make([]string, 0, len(out.InstanceTypes))
+	for _, t := range out.InstanceTypes {
+		sizes = append(sizes, *t.InstanceType)
+	}
+	return sizes, nil
+}
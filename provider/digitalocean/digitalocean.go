@@ -0,0 +1,77 @@
+// Package digitalocean adapts doclient.Client, the original DigitalOcean
+// droplet client, to the provider.Provider interface.
+package digitalocean
+
+import (
+	"strconv"
+
+	"github.com/dan-v/dosxvpn/doclient"
+)
+
+// Provider is the DigitalOcean implementation of provider.Provider.
+type Provider struct {
+	client *doclient.Client
+}
+
+// New returns a DigitalOcean Provider authenticated with token.
+func New(token string) *Provider {
+	return &Provider{client: doclient.New(token)}
+}
+
+func (p *Provider) FindInstance(name string) (string, error) {
+	dropletID, err := p.client.FindDropletByName(name)
+	if err != nil {
+		return "", err
+	}
+	if dropletID == 0 {
+		return "", nil
+	}
+	return strconv.Itoa(dropletID), nil
+}
+
+func (p *Provider) CreateInstance(name, region, size, userData, image string) (string, error) {
+	dropletID, err := p.client.CreateDroplet(name, region, size, userData, image)
+	if err != nil {
+		return "", err
+	}
+	return strconv.Itoa(dropletID), nil
+}
+
+func (p *Provider) WaitForIP(instanceID string) (string, error) {
+	dropletID, err := strconv.Atoi(instanceID)
+	if err != nil {
+		return "", err
+	}
+	return p.client.WaitForDropletIP(dropletID)
+}
+
+// CreateFirewall delegates to doclient's firewall rules, which already
+// cover both the IPsec and WireGuard ports dosxvpn may need open.
+func (p *Provider) CreateFirewall(name, instanceID, protocol string) (string, error) {
+	dropletID, err := strconv.Atoi(instanceID)
+	if err != nil {
+		return "", err
+	}
+	return p.client.CreateFirewall(name, dropletID)
+}
+
+func (p *Provider) DestroyInstance(instanceID, firewallID string) error {
+	dropletID, err := strconv.Atoi(instanceID)
+	if err != nil {
+		return err
+	}
+	if firewallID != "" {
+		if err := p.client.DestroyFirewall(firewallID); err != nil {
+			return err
+		}
+	}
+	return p.client.DestroyDroplet(dropletID)
+}
+
+func (p *Provider) ListRegions() ([]string, error) {
+	return p.client.ListRegions()
+}
+
+func (p *Provider) ListSizes() ([]string, error) {
+	return p.client.ListSizes()
+}
@@ -0,0 +1,208 @@
+// Package linode implements provider.Provider against the Linode API v4,
+// giving dosxvpn a second cloud backend alongside DigitalOcean.
+package linode
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const apiBase = "https://api.linode.com/v4"
+
+// Provider is the Linode implementation of provider.Provider.
+type Provider struct {
+	token      string
+	httpClient *http.Client
+}
+
+// New returns a Linode Provider authenticated with a personal access token.
+func New(token string) *Provider {
+	return &Provider{
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type linodeInstance struct {
+	ID     int      `json:"id"`
+	Label  string   `json:"label"`
+	IPv4   []string `json:"ipv4"`
+	Status string   `json:"status"`
+}
+
+// FindInstance returns the ID of the linode labeled name, or "" if none
+// exists.
+func (p *Provider) FindInstance(name string) (string, error) {
+	filter, err := json.Marshal(map[string]string{"label": name})
+	if err != nil {
+		return "", err
+	}
+
+	var out struct {
+		Data []linodeInstance `json:"data"`
+	}
+	if err := p.doFiltered("GET", "/linode/instances", nil, string(filter), &out); err != nil {
+		return "", err
+	}
+	if len(out.Data) == 0 {
+		return "", nil
+	}
+	return strconv.Itoa(out.Data[0].ID), nil
+}
+
+func (p *Provider) CreateInstance(name, region, size, userData, image string) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"label":    name,
+		"region":   region,
+		"type":     size,
+		"image":    image,
+		"metadata": map[string]string{"user_data": base64.StdEncoding.EncodeToString([]byte(userData))},
+		"booted":   true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var instance linodeInstance
+	if err := p.do("POST", "/linode/instances", body, &instance); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(instance.ID), nil
+}
+
+func (p *Provider) WaitForIP(instanceID string) (string, error) {
+	for attempt := 0; attempt < 60; attempt++ {
+		var instance linodeInstance
+		if err := p.do("GET", "/linode/instances/"+instanceID, nil, &instance); err != nil {
+			return "", err
+		}
+		if len(instance.IPv4) > 0 {
+			return instance.IPv4[0], nil
+		}
+		time.Sleep(5 * time.Second)
+	}
+	return "", fmt.Errorf("timed out waiting for linode %s to get an IP", instanceID)
+}
+
+func (p *Provider) CreateFirewall(name, instanceID, protocol string) (string, error) {
+	id, err := strconv.Atoi(instanceID)
+	if err != nil {
+		return "", err
+	}
+
+	inbound := []map[string]interface{}{
+		{"protocol": "TCP", "ports": "22", "addresses": map[string][]string{"ipv4": {"0.0.0.0/0"}}},
+	}
+	if protocol == "wireguard" {
+		inbound = append(inbound, map[string]interface{}{"protocol": "UDP", "ports": "51820", "addresses": map[string][]string{"ipv4": {"0.0.0.0/0"}}})
+	} else {
+		inbound = append(inbound, map[string]interface{}{"protocol": "UDP", "ports": "500,4500", "addresses": map[string][]string{"ipv4": {"0.0.0.0/0"}}})
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"label": name + "-fw",
+		"rules": map[string]interface{}{
+			"inbound_policy":  "DROP",
+			"outbound_policy": "ACCEPT",
+			"inbound":         inbound,
+		},
+		"devices": map[string]interface{}{
+			"linodes": []int{id},
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var out struct {
+		ID int `json:"id"`
+	}
+	if err := p.do("POST", "/networking/firewalls", body, &out); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(out.ID), nil
+}
+
+func (p *Provider) DestroyInstance(instanceID, firewallID string) error {
+	if firewallID != "" {
+		if err := p.do("DELETE", "/networking/firewalls/"+firewallID, nil, nil); err != nil {
+			return err
+		}
+	}
+	return p.do("DELETE", "/linode/instances/"+instanceID, nil, nil)
+}
+
+func (p *Provider) ListRegions() ([]string, error) {
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := p.do("GET", "/regions", nil, &out); err != nil {
+		return nil, err
+	}
+	regions := make([]string, 0, len(out.Data))
+	for _, r := range out.Data {
+		regions = append(regions, r.ID)
+	}
+	return regions, nil
+}
+
+func (p *Provider) ListSizes() ([]string, error) {
+	var out struct {
+		Data []struct {
+			ID string `json:"id"`
+		} `json:"data"`
+	}
+	if err := p.do("GET", "/linode/types", nil, &out); err != nil {
+		return nil, err
+	}
+	sizes := make([]string, 0, len(out.Data))
+	for _, t := range out.Data {
+		sizes = append(sizes, t.ID)
+	}
+	return sizes, nil
+}
+
+func (p *Provider) do(method, path string, body []byte, out interface{}) error {
+	return p.doFiltered(method, path, body, "", out)
+}
+
+// doFiltered is do with an optional Linode API "X-Filter" header, used to
+// list resources matching a field (e.g. {"label": name}) instead of paging
+// through everything.
+func (p *Provider) doFiltered(method, path string, body []byte, filter string, out interface{}) error {
+	req, err := http.NewRequest(method, apiBase+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.token)
+	req.Header.Set("Content-Type", "application/json")
+	if filter != "" {
+		req.Header.Set("X-Filter", filter)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("linode API %s %s: %d: %s", method, path, resp.StatusCode, string(respBody))
+	}
+	if out != nil {
+		return json.Unmarshal(respBody, out)
+	}
+	return nil
+}
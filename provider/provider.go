@@ -0,0 +1,35 @@
+// Package provider defines the cloud backend abstraction used by
+// deploy.Deployment so the same VPN provisioning flow (cloud-init userdata
+// plus SSH cert retrieval) can run against any supported cloud.
+package provider
+
+// Provider is implemented by each supported cloud backend (DigitalOcean,
+// Linode, AWS EC2, ...). Instance IDs are opaque strings since providers
+// disagree on their native ID type (DigitalOcean uses ints, AWS uses
+// "i-xxxx" strings, etc).
+type Provider interface {
+	// FindInstance looks up an existing instance named name and returns
+	// its ID, or "" if none exists. Callers check this before calling
+	// CreateInstance so that retrying after a transient error (e.g. the
+	// instance was created but the response failed to parse) finds the
+	// already-created instance instead of spawning a duplicate.
+	FindInstance(name string) (string, error)
+	// CreateInstance boots a new instance running userData as cloud-init
+	// and returns its instance ID.
+	CreateInstance(name, region, size, userData, image string) (string, error)
+	// WaitForIP blocks until the instance has a public IP and returns it.
+	WaitForIP(instanceID string) (string, error)
+	// CreateFirewall restricts the instance to the ports the given VPN
+	// protocol ("ipsec" or "wireguard") needs and returns an opaque ID
+	// for the firewall/security group it created.
+	CreateFirewall(name, instanceID, protocol string) (string, error)
+	// DestroyInstance tears down the instance and the firewall/security
+	// group identified by firewallID (the ID CreateFirewall returned),
+	// used by Rollback to clean up a failed deployment. firewallID is ""
+	// if CreateFirewall never ran.
+	DestroyInstance(instanceID, firewallID string) error
+	// ListRegions returns the region identifiers this provider accepts.
+	ListRegions() ([]string, error)
+	// ListSizes returns the instance size identifiers this provider accepts.
+	ListSizes() ([]string, error)
+}
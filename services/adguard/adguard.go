@@ -0,0 +1,94 @@
+// Package adguard templates an AdGuard Home server into the cloud-init
+// userdata produced by services.GenerateCloudConfig, as an alternative to
+// the Pi-hole based DNS filtering provided by services/pihole.
+package adguard
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// AdminUser is the AdGuard Home admin username dosxvpn seeds.
+const AdminUser = "admin"
+
+// WebPort is the port AdGuard Home's admin UI listens on.
+const WebPort = 3000
+
+// Service runs AdGuard Home via the adguard/adguardhome container, with its
+// config and query log persisted to a volume so they survive container
+// restarts.
+type Service struct {
+	AdminPassword string
+
+	// AllowedSubnet is the VPN subnet allowed to reach the admin UI; it's
+	// firewalled off from everywhere else.
+	AllowedSubnet string
+}
+
+// New creates an adguard Service with a freshly generated admin password,
+// seeded for clients on allowedSubnet.
+func New(allowedSubnet string) (*Service, error) {
+	password, err := generatePassword()
+	if err != nil {
+		return nil, err
+	}
+	return &Service{AdminPassword: password, AllowedSubnet: allowedSubnet}, nil
+}
+
+func generatePassword() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b[:]), nil
+}
+
+// CloudConfig renders the systemd unit that seeds AdGuardHome.yaml with the
+// admin password and brings the container up, restricting the admin UI to
+// AllowedSubnet via iptables since it must never be reachable from the
+// public internet.
+//
+// The published port is firewalled in the DOCKER-USER chain rather than
+// INPUT: Docker's -p publishing DNATs the port through nat/PREROUTING and
+// filters it via FORWARD/DOCKER, so INPUT rules never see that traffic at
+// all. DOCKER-USER runs before Docker's own FORWARD rules and is the
+// documented hook point for firewalling published container ports. The
+// matching -D before each -I makes the unit idempotent across restarts
+// instead of growing a new ACCEPT/DROP pair every time it starts.
+func (s *Service) CloudConfig() (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(s.AdminPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`write_files:
+  - path: /etc/adguard/conf/AdGuardHome.yaml
+    permissions: '0644'
+    content: |
+      users:
+        - name: %s
+          password: "%s"
+      http:
+        address: 0.0.0.0:%d
+coreos:
+  units:
+    - name: adguard.service
+      command: start
+      content: |
+        [Unit]
+        Description=AdGuard Home DNS filter
+        After=network-online.target
+        Wants=network-online.target
+        [Service]
+        ExecStartPre=-/usr/bin/docker rm -f adguard
+        ExecStartPre=-/usr/sbin/iptables -D DOCKER-USER -p tcp --dport %d -j DROP
+        ExecStartPre=-/usr/sbin/iptables -D DOCKER-USER -p tcp --dport %d -s %s -j ACCEPT
+        ExecStartPre=/usr/sbin/iptables -I DOCKER-USER 1 -p tcp --dport %d -j DROP
+        ExecStartPre=/usr/sbin/iptables -I DOCKER-USER 1 -p tcp --dport %d -s %s -j ACCEPT
+        ExecStart=/usr/bin/docker run --rm --name adguard -v /etc/adguard/conf:/opt/adguardhome/conf -v /etc/adguard/work:/opt/adguardhome/work -p 53:53/tcp -p 53:53/udp -p %d:%d/tcp adguard/adguardhome
+        ExecStop=/usr/bin/docker stop adguard
+        Restart=always
+`, AdminUser, hash, WebPort, WebPort, WebPort, s.AllowedSubnet, WebPort, WebPort, s.AllowedSubnet, WebPort, WebPort), nil
+}
@@ -0,0 +1,149 @@
+// Package wireguard templates a WireGuard server into the cloud-init
+// userdata produced by services.GenerateCloudConfig, as a lower-latency
+// alternative to the IPsec/StrongSwan setup provided by services/dosxvpn.
+package wireguard
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+
+	"golang.org/x/crypto/curve25519"
+)
+
+// ListenPort is the UDP port the WireGuard server listens on.
+const ListenPort = 51820
+
+// ServerSubnet is the private network the VPN assigns addresses from.
+const ServerSubnet = "10.6.0.0/24"
+
+// KeyPair is a WireGuard Curve25519 keypair, base64 encoded the same way
+// `wg genkey`/`wg pubkey` print them.
+type KeyPair struct {
+	PrivateKey string
+	PublicKey  string
+}
+
+// GenerateKeyPair creates a new WireGuard keypair locally, so neither key
+// ever needs to touch the droplet before it's embedded in cloud-init
+// userdata.
+func GenerateKeyPair() (*KeyPair, error) {
+	var priv [32]byte
+	if _, err := rand.Read(priv[:]); err != nil {
+		return nil, err
+	}
+	priv[0] &= 248
+	priv[31] &= 127
+	priv[31] |= 64
+
+	var pub [32]byte
+	curve25519.ScalarBaseMult(&pub, &priv)
+
+	return &KeyPair{
+		PrivateKey: base64.StdEncoding.EncodeToString(priv[:]),
+		PublicKey:  base64.StdEncoding.EncodeToString(pub[:]),
+	}, nil
+}
+
+// Service runs a WireGuard server via the linuxserver/wireguard container,
+// configured with a single peer matching the client keypair generated by
+// New.
+type Service struct {
+	ServerKeyPair *KeyPair
+	PeerKeyPair   *KeyPair
+	PeerAddress   string
+}
+
+// New creates a wireguard Service with freshly generated server and peer
+// keypairs.
+func New() (*Service, error) {
+	serverKeyPair, err := GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	peerKeyPair, err := GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	return &Service{
+		ServerKeyPair: serverKeyPair,
+		PeerKeyPair:   peerKeyPair,
+		PeerAddress:   "10.6.0.2/32",
+	}, nil
+}
+
+// ServerConfig renders the wg0.conf the server boots with.
+func (s *Service) ServerConfig() string {
+	return fmt.Sprintf(`[Interface]
+Address = 10.6.0.1/24
+ListenPort = %d
+PrivateKey = %s
+
+[Peer]
+PublicKey = %s
+AllowedIPs = %s
+`, ListenPort, s.ServerKeyPair.PrivateKey, s.PeerKeyPair.PublicKey, s.PeerAddress)
+}
+
+// PeerConfig renders the wg0.conf the client installs, pointed at dropletIP.
+func (s *Service) PeerConfig(dropletIP string) string {
+	return fmt.Sprintf(`[Interface]
+PrivateKey = %s
+Address = %s
+
+[Peer]
+PublicKey = %s
+Endpoint = %s:%d
+AllowedIPs = 0.0.0.0/0, ::/0
+PersistentKeepalive = 25
+`, s.PeerKeyPair.PrivateKey, s.PeerAddress, s.ServerKeyPair.PublicKey, dropletIP, ListenPort)
+}
+
+// CloudConfig renders the systemd unit that writes wg0.conf and brings the
+// WireGuard interface up via wg-quick, for inclusion alongside coreos and
+// the chosen DNS filter service in services.GenerateCloudConfig, in place
+// of dosxvpn.
+func (s *Service) CloudConfig() (string, error) {
+	return fmt.Sprintf(`write_files:
+  - path: /etc/wireguard/wg0.conf
+    permissions: '0600'
+    content: |
+%s
+coreos:
+  units:
+    - name: wireguard.service
+      command: start
+      content: |
+        [Unit]
+        Description=WireGuard VPN
+        After=network-online.target
+        Wants=network-online.target
+        [Service]
+        ExecStart=/usr/bin/docker run --rm --name wireguard --cap-add NET_ADMIN --cap-add SYS_MODULE -v /etc/wireguard:/config -p %d:%d/udp linuxserver/wireguard
+        ExecStop=/usr/bin/docker stop wireguard
+        Restart=always
+`, indent(s.ServerConfig(), "      "), ListenPort, ListenPort), nil
+}
+
+func indent(s, prefix string) string {
+	out := ""
+	for _, line := range splitLines(s) {
+		out += prefix + line + "\n"
+	}
+	return out
+}
+
+func splitLines(s string) []string {
+	var lines []string
+	start := 0
+	for i, r := range s {
+		if r == '\n' {
+			lines = append(lines, s[start:i])
+			start = i + 1
+		}
+	}
+	if start < len(s) {
+		lines = append(lines, s[start:])
+	}
+	return lines
+}
@@ -0,0 +1,96 @@
+package wireguard
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestGenerateKeyPair(t *testing.T) {
+	kp, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair: %v", err)
+	}
+
+	priv, err := base64.StdEncoding.DecodeString(kp.PrivateKey)
+	if err != nil {
+		t.Fatalf("PrivateKey isn't valid base64: %v", err)
+	}
+	if len(priv) != 32 {
+		t.Fatalf("PrivateKey decodes to %d bytes, want 32", len(priv))
+	}
+	pub, err := base64.StdEncoding.DecodeString(kp.PublicKey)
+	if err != nil {
+		t.Fatalf("PublicKey isn't valid base64: %v", err)
+	}
+	if len(pub) != 32 {
+		t.Fatalf("PublicKey decodes to %d bytes, want 32", len(pub))
+	}
+
+	other, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair: %v", err)
+	}
+	if kp.PrivateKey == other.PrivateKey {
+		t.Fatal("two calls to GenerateKeyPair produced the same private key")
+	}
+}
+
+func TestServerConfig(t *testing.T) {
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	cfg := s.ServerConfig()
+	for _, want := range []string{
+		"ListenPort = 51820",
+		"PrivateKey = " + s.ServerKeyPair.PrivateKey,
+		"PublicKey = " + s.PeerKeyPair.PublicKey,
+		"AllowedIPs = " + s.PeerAddress,
+	} {
+		if !strings.Contains(cfg, want) {
+			t.Errorf("ServerConfig missing %q:\n%s", want, cfg)
+		}
+	}
+}
+
+func TestPeerConfig(t *testing.T) {
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	cfg := s.PeerConfig("203.0.113.5")
+	for _, want := range []string{
+		"PrivateKey = " + s.PeerKeyPair.PrivateKey,
+		"Address = " + s.PeerAddress,
+		"PublicKey = " + s.ServerKeyPair.PublicKey,
+		"Endpoint = 203.0.113.5:51820",
+		"AllowedIPs = 0.0.0.0/0, ::/0",
+	} {
+		if !strings.Contains(cfg, want) {
+			t.Errorf("PeerConfig missing %q:\n%s", want, cfg)
+		}
+	}
+}
+
+func TestCloudConfigIndentsServerConfig(t *testing.T) {
+	s, err := New()
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	out, err := s.CloudConfig()
+	if err != nil {
+		t.Fatalf("CloudConfig: %v", err)
+	}
+	for _, line := range splitLines(s.ServerConfig()) {
+		if line == "" {
+			continue
+		}
+		if !strings.Contains(out, "      "+line) {
+			t.Errorf("CloudConfig doesn't contain server config line indented 6 spaces: %q", line)
+		}
+	}
+}
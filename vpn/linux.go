@@ -0,0 +1,82 @@
+package vpn
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// LinuxAddVPN imports the dosxvpn CA certificate and brings up an IKEv2
+// connection via NetworkManager's nmcli, falling back to generating a
+// swanctl.conf and loading it with strongswan's swanctl when nmcli isn't on
+// PATH.
+func LinuxAddVPN(name, serverAddress, caCertPath string) error {
+	if _, err := exec.LookPath("nmcli"); err == nil {
+		if err := exec.Command("nmcli", "connection", "add", "type", "vpn", "con-name", name,
+			"vpn-type", "strongswan",
+			"vpn.service-type", "org.freedesktop.NetworkManager.strongswan",
+			"vpn.data", fmt.Sprintf("address=%s, certificate=%s, method=key", serverAddress, caCertPath),
+		).Run(); err != nil {
+			return err
+		}
+		return exec.Command("nmcli", "connection", "up", name).Run()
+	}
+
+	if err := writeSwanctlConfig(name, serverAddress, caCertPath); err != nil {
+		return err
+	}
+	return exec.Command("swanctl", "--load-all").Run()
+}
+
+// swanctlConfDir and swanctlCACertDir are where swanctl --load-all expects
+// connection definitions and trusted CA certificates, respectively.
+const (
+	swanctlConfDir   = "/etc/swanctl/conf.d"
+	swanctlCACertDir = "/etc/swanctl/x509ca"
+)
+
+// writeSwanctlConfig renders a swanctl.conf connection definition for name
+// and copies caCertPath into swanctl's CA cert directory, since --load-all
+// only picks up connections and certificates it finds in its own conf.d/
+// x509ca directories rather than an arbitrary path.
+func writeSwanctlConfig(name, serverAddress, caCertPath string) error {
+	caCert, err := ioutil.ReadFile(caCertPath)
+	if err != nil {
+		return err
+	}
+	caCertName := name + "-ca.pem"
+	if err := os.MkdirAll(swanctlCACertDir, 0755); err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(swanctlCACertDir, caCertName), caCert, 0644); err != nil {
+		return err
+	}
+
+	conf := fmt.Sprintf(`connections {
+  %s {
+    version = 2
+    remote_addrs = %s
+    local {
+      auth = pubkey
+    }
+    remote {
+      auth = pubkey
+      cacerts = %s
+    }
+    children {
+      %s {
+        remote_ts = 0.0.0.0/0
+        start_action = start
+      }
+    }
+  }
+}
+`, name, serverAddress, caCertName, name)
+
+	if err := os.MkdirAll(swanctlConfDir, 0755); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(swanctlConfDir, name+".conf"), []byte(conf), 0644)
+}
@@ -0,0 +1,9 @@
+package vpn
+
+import "os/exec"
+
+// OSXAddWireguard brings up a WireGuard tunnel on OSX from a peer config
+// file using wg-quick, the same tool `brew install wireguard-tools` ships.
+func OSXAddWireguard(peerConfigPath string) error {
+	return exec.Command("wg-quick", "up", peerConfigPath).Run()
+}
@@ -0,0 +1,10 @@
+package vpn
+
+import "os/exec"
+
+// WindowsAddVPN installs the IKEv2 VPN connection described by scriptPath,
+// the .ps1 genconfig.GenerateWindowsScript renders alongside the
+// .mobileconfig/.sswan bundle, via powershell's Add-VpnConnection.
+func WindowsAddVPN(scriptPath string) error {
+	return exec.Command("powershell", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", scriptPath).Run()
+}